@@ -0,0 +1,107 @@
+package clustering
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Number of uniform reference datasets sampled per candidate k when
+// estimating the gap statistic.
+const gapReferences = 10
+
+// Picks the number of clusters in the range 1..kmax that best fits vecs,
+// using Tibshirani, Walther and Hastie's gap statistic. For each candidate k
+// it clusters vecs and compares the resulting within-cluster dispersion
+// against that of gapReferences datasets sampled uniformly from the bounding
+// box of vecs, then returns the smallest k whose gap is within one standard
+// error of the gap at k+1. Falls back to kmax if no such k is found.
+func KmeansEstimateK(vecs [][]float64, kmax int) int {
+	if kmax < 1 {
+		panic(fmt.Sprint("Bad kmax:", kmax))
+	}
+	if kmax > len(vecs) {
+		kmax = len(vecs)
+	}
+
+	lo, hi := boundingBox(vecs)
+
+	logWk := make([]float64, kmax+1)
+	gap := make([]float64, kmax+1)
+	sk := make([]float64, kmax+1)
+
+	for k := 1; k <= kmax; k++ {
+		means, tags := Kmeans(vecs, k)
+		logWk[k] = math.Log(withinClusterSS(vecs, means, tags))
+
+		refLogs := make([]float64, gapReferences)
+		for i := range refLogs {
+			ref := sampleUniform(lo, hi, len(vecs))
+			refMeans, refTags := Kmeans(ref, k)
+			refLogs[i] = math.Log(withinClusterSS(ref, refMeans, refTags))
+		}
+
+		eLogWk, sd := meanAndStd(refLogs)
+		gap[k] = eLogWk - logWk[k]
+		sk[k] = sd * math.Sqrt(1+1/float64(gapReferences))
+	}
+
+	for k := 1; k < kmax; k++ {
+		if gap[k] >= gap[k+1]-sk[k+1] {
+			return k
+		}
+	}
+
+	return kmax
+}
+
+// Sum of squared distances of vecs from their assigned means.
+func withinClusterSS(vecs, means [][]float64, tags []int) float64 {
+	return MeanSquaredError(vecs, means, tags) * float64(len(vecs))
+}
+
+// Returns the component-wise minimum and maximum over vecs.
+func boundingBox(vecs [][]float64) (lo, hi []float64) {
+	lo = make([]float64, len(vecs[0]))
+	hi = make([]float64, len(vecs[0]))
+	copy(lo, vecs[0])
+	copy(hi, vecs[0])
+
+	for _, v := range vecs {
+		for i, x := range v {
+			lo[i] = math.Min(lo[i], x)
+			hi[i] = math.Max(hi[i], x)
+		}
+	}
+
+	return
+}
+
+// Generates n vectors sampled uniformly from the box spanned by lo and hi.
+func sampleUniform(lo, hi []float64, n int) [][]float64 {
+	result := make([][]float64, n)
+	for i := range result {
+		v := make([]float64, len(lo))
+		for j := range v {
+			v[j] = lo[j] + rand.Float64()*(hi[j]-lo[j])
+		}
+		result[i] = v
+	}
+	return result
+}
+
+// Returns the mean and (population) standard deviation of xs.
+func meanAndStd(xs []float64) (mean, std float64) {
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+
+	for _, x := range xs {
+		d := x - mean
+		std += d * d
+	}
+	std = math.Sqrt(std / float64(len(xs)))
+
+	return
+}
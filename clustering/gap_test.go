@@ -0,0 +1,33 @@
+package clustering
+
+import "testing"
+
+func TestKmeansEstimateK(t *testing.T) {
+	centers := [][]float64{{0, 0}, {30, 0}, {0, 30}}
+	vecs := make([][]float64, 0, len(centers)*15)
+	for _, c := range centers {
+		for i := 0; i < 15; i++ {
+			vecs = append(vecs, []float64{
+				c[0] + float64(i%3)*0.1,
+				c[1] + float64(i%2)*0.1,
+			})
+		}
+	}
+
+	k := KmeansEstimateK(vecs, 6)
+	if k != len(centers) {
+		t.Errorf("KmeansEstimateK = %d, want %d", k, len(centers))
+	}
+}
+
+func TestBoundingBox(t *testing.T) {
+	vecs := [][]float64{{1, 5}, {-2, 3}, {4, -1}}
+	lo, hi := boundingBox(vecs)
+	wantLo := []float64{-2, -1}
+	wantHi := []float64{4, 5}
+	for i := range wantLo {
+		if lo[i] != wantLo[i] || hi[i] != wantHi[i] {
+			t.Fatalf("boundingBox = (%v, %v), want (%v, %v)", lo, hi, wantLo, wantHi)
+		}
+	}
+}
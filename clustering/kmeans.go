@@ -5,14 +5,47 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
 
 	"github.com/fluhus/gostuff/vectors"
 )
 
+// Clusterable is the element type accepted by KmeansWith. It places no
+// constraints of its own; the caller's dist and mean functions define what
+// operations the elements support.
+type Clusterable interface{}
+
+// KmeansOptions configures the behavior of KmeansWith.
+type KmeansOptions struct {
+	// SkipTriangleInequality disables the triangle-inequality pruning used
+	// when searching for the nearest centroid. That optimization is only
+	// valid when dist is a metric (satisfies the triangle inequality), as L2
+	// distance does. Set this to true when dist doesn't have that property,
+	// e.g. cosine distance.
+	SkipTriangleInequality bool
+
+	// Parallelism is the number of goroutines used for the assignment and
+	// mean-update steps. 0 means use runtime.NumCPU().
+	Parallelism int
+}
+
 // Performs k-means clustering on the given data. Each vector is an element in
 // the clustering. Returns the generated means, and the tag each element was
 // given.
 func Kmeans(vecs [][]float64, k int) (means [][]float64, tags []int) {
+	return KmeansWith(vecs, k, vectors.L2, meanOfVectors, KmeansOptions{})
+}
+
+// Performs k-means clustering on elements of any type, using the given
+// distance and mean functions. dist should be a metric (satisfy the triangle
+// inequality, as L2 distance does) so that the nearest-centroid search can
+// prune using it; set opts.SkipTriangleInequality when that doesn't hold,
+// e.g. for cosine or other non-metric distances.
+// The returned means are independent values, not aliases into vecs.
+func KmeansWith[T Clusterable](vecs []T, k int, dist func(a, b T) float64,
+	mean func([]T) T, opts KmeansOptions) (means []T, tags []int) {
 	// K must be at least 1.
 	if k < 1 {
 		panic(fmt.Sprint("Bad k:", k))
@@ -28,126 +61,201 @@ func Kmeans(vecs [][]float64, k int) (means [][]float64, tags []int) {
 		k = len(vecs)
 	}
 
-	// First tagging.
-	means = initialMeans(vecs, k)
-	tags = tag(vecs, means, make([]int, len(vecs)))
-	dist := MeanSquaredError(vecs, means, tags)
-	distOld := 2 * dist
+	// First tagging. initialMeansWith's seeds alias elements of vecs, so run
+	// them through mean to get independent values before anything mutates
+	// them in place.
+	means = initialMeansWith(vecs, k, dist)
+	for i := range means {
+		means[i] = mean([]T{means[i]})
+	}
+	tags = tagWith(vecs, means, make([]int, len(vecs)), dist, opts.SkipTriangleInequality, opts.Parallelism)
+	d := meanSquaredErrorWith(vecs, means, tags, dist)
+	dOld := 2 * d
 
 	// Iterate until converged.
-	for dist > distOld || dist/distOld < 0.999 {
-		distOld = dist
-		means = findMeans(vecs, tags, k)
-		tags = tag(vecs, means, tags)
-		dist = MeanSquaredError(vecs, means, tags)
+	for d > dOld || d/dOld < 0.999 {
+		dOld = d
+		means = findMeansWith(vecs, tags, means, mean, opts.Parallelism)
+		tags = tagWith(vecs, means, tags, dist, opts.SkipTriangleInequality, opts.Parallelism)
+		d = meanSquaredErrorWith(vecs, means, tags, dist)
 	}
 
 	return
 }
 
-// Tags each row with the index of its nearest centroid. The old tags are used
-// for optimization.
-func tag(vecs, means [][]float64, oldTags []int) []int {
+// Resolves the effective number of goroutines to use for n elements, given a
+// requested parallelism (0 meaning auto).
+func resolveParallelism(p, n int) int {
+	if p <= 0 {
+		p = runtime.NumCPU()
+	}
+	if p > n {
+		p = n
+	}
+	if p < 1 {
+		p = 1
+	}
+	return p
+}
+
+// Splits n elements into evenly sized [start, end) chunks for p workers.
+func chunkRanges(n, p int) [][2]int {
+	size := (n + p - 1) / p
+	ranges := make([][2]int, 0, p)
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
+
+// Tags each element with the index of its nearest centroid. The old tags are
+// used for optimization. The triangle-inequality pruning is skipped when
+// skipTriangleInequality is set, since it otherwise assumes dist is a metric.
+// The work is sharded across parallelism goroutines (0 meaning auto), each
+// writing to its own segment of the result.
+func tagWith[T Clusterable](vecs, means []T, oldTags []int,
+	dist func(a, b T) float64, skipTriangleInequality bool, parallelism int) []int {
 	if len(means) == 0 {
 		panic("Cannot tag on 0 centroids.")
 	}
 
 	// Create a distance matrix of means from one another.
-	meansd := make([][]float64, len(means))
-	for i := range meansd {
-		meansd[i] = make([]float64, len(means))
-		for j := range means {
-			meansd[i][j] = vectors.L2(means[i], means[j])
+	var meansd [][]float64
+	if !skipTriangleInequality {
+		meansd = make([][]float64, len(means))
+		for i := range meansd {
+			meansd[i] = make([]float64, len(means))
+			for j := range means {
+				meansd[i][j] = dist(means[i], means[j])
+			}
 		}
 	}
 
 	tags := make([]int, len(vecs))
 
-	// Go over vectors.
-	for i := range vecs {
-		// Find nearest centroid.
-		tags[i] = oldTags[i]
-		d := vectors.L2(means[oldTags[i]], vecs[i])
+	var wg sync.WaitGroup
+	for _, r := range chunkRanges(len(vecs), resolveParallelism(parallelism, len(vecs))) {
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				// Find nearest centroid.
+				tags[i] = oldTags[i]
+				d := dist(means[oldTags[i]], vecs[i])
 
-		for j := 0; j < len(means); j++ {
-			// Use triangle inequality to skip means that are too distant.
-			if j == tags[i] || meansd[j][tags[i]] >= 2*d {
-				continue
-			}
+				for j := 0; j < len(means); j++ {
+					if j == tags[i] {
+						continue
+					}
+					// Use triangle inequality to skip means that are too distant.
+					if !skipTriangleInequality && meansd[j][tags[i]] >= 2*d {
+						continue
+					}
 
-			dj := vectors.L2(means[j], vecs[i])
-			if dj < d {
-				d = dj
-				tags[i] = j
+					dj := dist(means[j], vecs[i])
+					if dj < d {
+						d = dj
+						tags[i] = j
+					}
+				}
 			}
-		}
+		}(r[0], r[1])
 	}
+	wg.Wait()
 
 	return tags
 }
 
-// Calculates the new means, according to average of tagged rows in each
-// group.
-func findMeans(vecs [][]float64, tags []int, k int) [][]float64 {
-	// Initialize new arrays.
-	means := make([][]float64, k)
-	for i := range means {
-		means[i] = make([]float64, len(vecs[0]))
-	}
-	counts := make([]int, k)
+// Calculates the new means, according to the given mean function applied to
+// the elements tagged in each group. Grouping is sharded across parallelism
+// goroutines (0 meaning auto), each accumulating into its own thread-local
+// groups before they're merged and reduced with mean. A cluster that ends up
+// with no assigned elements (a normal occurrence in Lloyd's algorithm) keeps
+// its mean from prevMeans instead of a zero-valued T, since T's zero value
+// isn't necessarily a valid element (e.g. nil for []float64).
+func findMeansWith[T Clusterable](vecs []T, tags []int, prevMeans []T, mean func([]T) T, parallelism int) []T {
+	k := len(prevMeans)
+	ranges := chunkRanges(len(vecs), resolveParallelism(parallelism, len(vecs)))
 
-	// Sum all vectors according to tags.
-	for i := range vecs {
-		counts[tags[i]]++
-		vectors.Add(means[tags[i]], vecs[i])
+	partials := make([][][]T, len(ranges))
+	var wg sync.WaitGroup
+	for w, r := range ranges {
+		wg.Add(1)
+		go func(w, start, end int) {
+			defer wg.Done()
+			groups := make([][]T, k)
+			for i := start; i < end; i++ {
+				groups[tags[i]] = append(groups[tags[i]], vecs[i])
+			}
+			partials[w] = groups
+		}(w, r[0], r[1])
 	}
+	wg.Wait()
 
-	// Divide by count.
-	for i := range means {
-		if counts[i] != 0 {
-			vectors.Mul(means[i], 1/float64(counts[i]))
+	means := make([]T, k)
+	for c := 0; c < k; c++ {
+		var group []T
+		for _, part := range partials {
+			group = append(group, part[c]...)
+		}
+		if len(group) > 0 {
+			means[c] = mean(group)
+		} else {
+			means[c] = prevMeans[c]
 		}
 	}
 
 	return means
 }
 
-// Picks the initial means with the K-means++ algorithm.
-func initialMeans(vecs [][]float64, k int) [][]float64 {
-	result := make([][]float64, k)
-	perm := rand.Perm(len(vecs))
-
-	// Pick each mean.
-	distance := make([]float64, len(vecs))
-	for i := range result {
-		result[i] = make([]float64, len(vecs[0]))
-
-		// First mean is first.
-		if i == 0 {
-			copy(result[0], vecs[perm[0]])
-			for _, j := range perm {
-				distance[j] = vectors.L2(vecs[j], result[0])
-			}
-			continue
-		}
+// Returns the element-wise average of vs. Assumes vs is non-empty and all
+// elements have the same length.
+func meanOfVectors(vs [][]float64) []float64 {
+	m := make([]float64, len(vs[0]))
+	for _, v := range vs {
+		vectors.Add(m, v)
+	}
+	vectors.Mul(m, 1/float64(len(vs)))
+	return m
+}
+
+// Picks the initial means with the K-means++ algorithm. d2[i] tracks the
+// squared distance from vecs[i] to the nearest mean picked so far; each
+// subsequent mean is chosen by bisecting the cumulative sum of d2, which
+// samples a point with probability proportional to its d^2, same as a linear
+// scan would. The selection itself is an O(log n) binary search; building cum
+// and updating d2 still take O(n) per pick.
+func initialMeansWith[T Clusterable](vecs []T, k int, dist func(a, b T) float64) []T {
+	n := len(vecs)
+	result := make([]T, k)
+	d2 := make([]float64, n)
+	cum := make([]float64, n)
+
+	// First mean is uniformly random.
+	result[0] = vecs[rand.Intn(n)]
+	for i, v := range vecs {
+		d := dist(v, result[0])
+		d2[i] = d * d
+	}
 
-		// Find next mean.
+	for i := 1; i < k; i++ {
 		sum := 0.0
-		newMean := -1
-		for _, j := range perm {
-			// Pick element relative to d^2.
-			d := distance[j]
-			sum += d * d
-			if rand.Float64()*sum <= d*d {
-				newMean = j
-			}
+		for j, d := range d2 {
+			sum += d
+			cum[j] = sum
 		}
-		copy(result[i], vecs[newMean])
+
+		r := rand.Float64() * sum
+		result[i] = vecs[sort.SearchFloat64s(cum, r)]
 
 		// Update distances.
-		for _, j := range perm {
-			d := vectors.L2(vecs[j], result[i])
-			distance[j] = math.Min(distance[j], d)
+		for j, v := range vecs {
+			d := dist(v, result[i])
+			d2[j] = math.Min(d2[j], d*d)
 		}
 	}
 
@@ -156,7 +264,7 @@ func initialMeans(vecs [][]float64, k int) [][]float64 {
 
 // Calculates the average squared-distance of elements from their assigned
 // means.
-func MeanSquaredError(vecs, means [][]float64, tags []int) float64 {
+func meanSquaredErrorWith[T Clusterable](vecs, means []T, tags []int, dist func(a, b T) float64) float64 {
 	if len(tags) != len(vecs) {
 		panic(fmt.Sprintf("Non-matching lengths of matrix and tags: %d, %d",
 			len(vecs), len(tags)))
@@ -167,9 +275,15 @@ func MeanSquaredError(vecs, means [][]float64, tags []int) float64 {
 
 	d := 0.0
 	for i := range tags {
-		dist := vectors.L2(means[tags[i]], vecs[i])
-		d += dist * dist
+		dd := dist(means[tags[i]], vecs[i])
+		d += dd * dd
 	}
 
 	return d / float64(len(vecs))
-}
\ No newline at end of file
+}
+
+// Calculates the average squared-distance of elements from their assigned
+// means.
+func MeanSquaredError(vecs, means [][]float64, tags []int) float64 {
+	return meanSquaredErrorWith(vecs, means, tags, vectors.L2)
+}
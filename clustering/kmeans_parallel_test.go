@@ -0,0 +1,41 @@
+package clustering
+
+import (
+	"testing"
+
+	"github.com/fluhus/gostuff/vectors"
+)
+
+func TestKmeansWithParallelism(t *testing.T) {
+	vecs := twoBlobs(200, 100)
+
+	means, tags := KmeansWith(vecs, 2, vectors.L2, meanOfVectors,
+		KmeansOptions{Parallelism: 4})
+	if len(means) != 2 || len(tags) != len(vecs) {
+		t.Fatalf("unexpected shapes: means=%d tags=%d", len(means), len(tags))
+	}
+	if tags[0] == tags[1] {
+		t.Errorf("expected the two interleaved groups to land in different clusters")
+	}
+}
+
+func TestKmeansWithParallelismMatchesSerial(t *testing.T) {
+	vecs := twoBlobs(100, 50)
+
+	_, serialTags := KmeansWith(vecs, 2, vectors.L2, meanOfVectors, KmeansOptions{Parallelism: 1})
+	_, parallelTags := KmeansWith(vecs, 2, vectors.L2, meanOfVectors, KmeansOptions{Parallelism: 8})
+
+	// The two runs pick different random seeds, so clusters may come out
+	// with swapped labels; compare agreement instead of exact tags.
+	same, diff := 0, 0
+	for i := range serialTags {
+		if (serialTags[i] == serialTags[0]) == (parallelTags[i] == parallelTags[0]) {
+			same++
+		} else {
+			diff++
+		}
+	}
+	if diff > same {
+		t.Errorf("parallel and serial clusterings disagree on most elements: same=%d diff=%d", same, diff)
+	}
+}
@@ -0,0 +1,40 @@
+package clustering
+
+import (
+	"testing"
+
+	"github.com/fluhus/gostuff/vectors"
+)
+
+func TestInitialMeansWithPicksDistinctFarPoints(t *testing.T) {
+	vecs := [][]float64{
+		{0, 0}, {0.1, 0}, {0, 0.1},
+		{50, 50}, {50.1, 50}, {50, 50.1},
+	}
+
+	means := initialMeansWith(vecs, 2, vectors.L2)
+	if len(means) != 2 {
+		t.Fatalf("len(means)=%d, want 2", len(means))
+	}
+	if vectors.L2(means[0], means[1]) < 40 {
+		t.Errorf("seeds %v and %v are too close, want one from each far-apart group", means[0], means[1])
+	}
+}
+
+func TestInitialMeansWithReturnsVecsElements(t *testing.T) {
+	vecs := [][]float64{{1, 2}, {3, 4}, {5, 6}, {7, 8}}
+
+	means := initialMeansWith(vecs, 3, vectors.L2)
+	for _, m := range means {
+		found := false
+		for _, v := range vecs {
+			if vectors.L2(m, v) == 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("mean %v is not one of vecs", m)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package clustering
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fluhus/gostuff/vectors"
+)
+
+func TestKmeansConverges(t *testing.T) {
+	vecs := [][]float64{
+		{0, 0}, {0, 1}, {1, 0},
+		{10, 10}, {10, 11}, {11, 10},
+	}
+	means, tags := Kmeans(vecs, 2)
+	if len(means) != 2 {
+		t.Fatalf("len(means)=%d, want 2", len(means))
+	}
+	if tags[0] == tags[3] {
+		t.Fatalf("expected separate clusters for the near and far groups")
+	}
+	for i := 1; i < 3; i++ {
+		if tags[i] != tags[0] {
+			t.Errorf("tags[%d]=%d, want %d (same cluster as tags[0])", i, tags[i], tags[0])
+		}
+	}
+	for i := 4; i < 6; i++ {
+		if tags[i] != tags[3] {
+			t.Errorf("tags[%d]=%d, want %d (same cluster as tags[3])", i, tags[i], tags[3])
+		}
+	}
+}
+
+func TestKmeansWithCustomDistance(t *testing.T) {
+	// Hamming distance over bit vectors, which isn't a metric KmeansWith was
+	// originally hardcoded for, hence SkipTriangleInequality.
+	vecs := [][]int{
+		{0, 0, 0}, {0, 0, 1}, {1, 1, 1}, {1, 1, 0},
+	}
+	dist := func(a, b []int) float64 {
+		d := 0.0
+		for i := range a {
+			if a[i] != b[i] {
+				d++
+			}
+		}
+		return d
+	}
+	mean := func(vs [][]int) []int {
+		counts := make([]int, len(vs[0]))
+		for _, v := range vs {
+			for i, x := range v {
+				counts[i] += x
+			}
+		}
+		m := make([]int, len(counts))
+		for i, c := range counts {
+			if c*2 >= len(vs) {
+				m[i] = 1
+			}
+		}
+		return m
+	}
+
+	means, tags := KmeansWith(vecs, 2, dist, mean, KmeansOptions{SkipTriangleInequality: true})
+	if len(means) != 2 || len(tags) != len(vecs) {
+		t.Fatalf("unexpected shapes: means=%d tags=%d", len(means), len(tags))
+	}
+}
+
+// Regression test: a cluster that ends up with no assigned elements (a
+// normal Lloyd's-algorithm occurrence) must keep its previous mean rather
+// than a zero-valued (nil) one, which used to crash the next tagWith call's
+// triangle-inequality distance matrix.
+func TestFindMeansWithKeepsEmptyClusterMean(t *testing.T) {
+	prev := [][]float64{{1, 1}, {2, 2}, {3, 3}}
+	vecs := [][]float64{{1, 1}, {1.1, 1.1}, {2, 2}}
+	tags := []int{0, 0, 1} // cluster 2 gets no elements.
+
+	means := findMeansWith(vecs, tags, prev, meanOfVectors, 1)
+	if means[2] == nil {
+		t.Fatalf("empty cluster's mean is nil, want fallback to prevMeans[2]")
+	}
+	if !reflect.DeepEqual(means[2], prev[2]) {
+		t.Errorf("means[2] = %v, want %v (prevMeans)", means[2], prev[2])
+	}
+
+	// Must not panic when fed back into the triangle-inequality matrix.
+	tagWith(vecs, means, tags, vectors.L2, false, 1)
+}
@@ -0,0 +1,78 @@
+package clustering
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/fluhus/gostuff/vectors"
+)
+
+// Performs k-means clustering using Sculley's mini-batch algorithm. Centroids
+// are seeded with k-means++, same as Kmeans, and then refined over
+// iterations rounds, each of which samples batchSize vectors uniformly at
+// random, assigns each to its nearest centroid, and nudges that centroid
+// towards it with a per-centroid learning rate of 1/count, where count is
+// the number of points assigned to it so far across all iterations. Returns
+// the generated means, and the tag each element was given by a final full
+// pass over vecs.
+//
+// This trades some accuracy for being able to cluster datasets that don't
+// fit comfortably in memory, or where full-batch iterations are too slow,
+// while converging to essentially the same solution as Kmeans.
+func MiniBatchKmeans(vecs [][]float64, k, batchSize, iterations int) (means [][]float64, tags []int) {
+	if k < 1 {
+		panic(fmt.Sprint("Bad k:", k))
+	}
+	if len(vecs) == 0 {
+		panic("Cannot cluster 0 vectors.")
+	}
+	if batchSize < 1 {
+		panic(fmt.Sprint("Bad batchSize:", batchSize))
+	}
+	if k > len(vecs) {
+		k = len(vecs)
+	}
+
+	// initialMeansWith's seeds alias elements of vecs; copy them so the
+	// update loop below doesn't mutate the caller's input.
+	means = initialMeansWith(vecs, k, vectors.L2)
+	for i := range means {
+		m := make([]float64, len(means[i]))
+		copy(m, means[i])
+		means[i] = m
+	}
+	counts := make([]int, k)
+
+	for it := 0; it < iterations; it++ {
+		for b := 0; b < batchSize; b++ {
+			x := vecs[rand.Intn(len(vecs))]
+			c := nearestMean(x, means)
+			counts[c]++
+			lr := 1 / float64(counts[c])
+			for i := range means[c] {
+				means[c][i] += lr * (x[i] - means[c][i])
+			}
+		}
+	}
+
+	tags = make([]int, len(vecs))
+	for i, v := range vecs {
+		tags[i] = nearestMean(v, means)
+	}
+
+	return
+}
+
+// Returns the index of the mean closest to v.
+func nearestMean(v []float64, means [][]float64) int {
+	best := 0
+	bestD := vectors.L2(v, means[0])
+	for i := 1; i < len(means); i++ {
+		d := vectors.L2(v, means[i])
+		if d < bestD {
+			bestD = d
+			best = i
+		}
+	}
+	return best
+}
@@ -0,0 +1,29 @@
+package clustering
+
+import "testing"
+
+func TestMiniBatchKmeans(t *testing.T) {
+	vecs := twoBlobs(100, 40)
+
+	means, tags := MiniBatchKmeans(vecs, 2, 20, 50)
+	if len(means) != 2 || len(tags) != len(vecs) {
+		t.Fatalf("unexpected shapes: means=%d tags=%d", len(means), len(tags))
+	}
+	if tags[0] == tags[1] {
+		t.Errorf("expected the two far-apart groups to land in different clusters")
+	}
+	for i := 2; i < len(vecs); i += 2 {
+		if tags[i] != tags[0] {
+			t.Errorf("tags[%d]=%d, want %d (same cluster as tags[0])", i, tags[i], tags[0])
+		}
+	}
+}
+
+func TestMiniBatchKmeansBadArgs(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for k=0")
+		}
+	}()
+	MiniBatchKmeans([][]float64{{1, 2}}, 0, 1, 1)
+}
@@ -0,0 +1,59 @@
+package clustering
+
+import "github.com/fluhus/gostuff/vectors"
+
+// OnlineKmeans is a stateful, incremental k-means clusterer for data that
+// arrives one vector at a time, such as streaming sensor readings or
+// continuously arriving embeddings, where re-running Kmeans over the whole
+// dataset isn't practical. Centroids are seeded lazily from the first k
+// distinct vectors passed to Learn, then refined with exponential-decay
+// updates.
+type OnlineKmeans struct {
+	alpha     float64
+	centroids [][]float64
+	seeded    int
+}
+
+// Creates a new OnlineKmeans with k centroids of the given dimension, using
+// alpha as the exponential-decay learning rate. Centroids are unseeded until
+// Learn has seen k distinct vectors.
+func NewOnlineKmeans(k, dim int, alpha float64) *OnlineKmeans {
+	centroids := make([][]float64, k)
+	for i := range centroids {
+		centroids[i] = make([]float64, dim)
+	}
+	return &OnlineKmeans{alpha: alpha, centroids: centroids}
+}
+
+// Updates the clusterer with a new observation. Until k distinct vectors
+// have been seen, vec seeds the next unseeded centroid; a vec equal to an
+// already seeded centroid is ignored. After that, Learn
+// finds the nearest centroid c and updates it as
+// centroids[c] += alpha * (vec - centroids[c]).
+func (o *OnlineKmeans) Learn(vec []float64) {
+	if o.seeded < len(o.centroids) {
+		for i := 0; i < o.seeded; i++ {
+			if vectors.L2(vec, o.centroids[i]) == 0 {
+				return
+			}
+		}
+		copy(o.centroids[o.seeded], vec)
+		o.seeded++
+		return
+	}
+
+	centroid := o.centroids[nearestMean(vec, o.centroids)]
+	for i := range centroid {
+		centroid[i] += o.alpha * (vec[i] - centroid[i])
+	}
+}
+
+// Returns the index of the centroid nearest to vec.
+func (o *OnlineKmeans) Predict(vec []float64) int {
+	return nearestMean(vec, o.centroids)
+}
+
+// Returns the current centroids.
+func (o *OnlineKmeans) Centroids() [][]float64 {
+	return o.centroids
+}
@@ -0,0 +1,29 @@
+package clustering
+
+import "testing"
+
+func TestOnlineKmeansSeedsThenLearns(t *testing.T) {
+	o := NewOnlineKmeans(2, 2, 0.5)
+
+	o.Learn([]float64{0, 0})
+	o.Learn([]float64{10, 10})
+
+	centroids := o.Centroids()
+	if centroids[0][0] != 0 || centroids[1][0] != 10 {
+		t.Fatalf("centroids after seeding = %v, want [[0 0] [10 10]]", centroids)
+	}
+
+	for i := 0; i < 20; i++ {
+		o.Learn([]float64{1, 1})
+	}
+
+	if o.Predict([]float64{1, 1}) != 0 {
+		t.Errorf("Predict([1 1]) = %d, want 0", o.Predict([]float64{1, 1}))
+	}
+	if o.Predict([]float64{9, 9}) != 1 {
+		t.Errorf("Predict([9 9]) = %d, want 1", o.Predict([]float64{9, 9}))
+	}
+	if centroids[0][0] == 0 {
+		t.Errorf("centroid 0 did not move towards repeated [1 1] observations")
+	}
+}
@@ -0,0 +1,13 @@
+package clustering
+
+// Builds n pairs of vectors forming two interleaved blobs: one centered at
+// the origin, the other offset by sep in both dimensions. Shared by tests
+// that just need two separable clusters to check against.
+func twoBlobs(n int, sep float64) [][]float64 {
+	vecs := make([][]float64, 0, n*2)
+	for i := 0; i < n; i++ {
+		vecs = append(vecs, []float64{float64(i % 5), float64(i % 3)})
+		vecs = append(vecs, []float64{sep + float64(i%5), sep + float64(i%3)})
+	}
+	return vecs
+}